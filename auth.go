@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AuthProvider supplies a GitHub access token to authenticate API requests.
+// Implementations are responsible for their own caching/refresh.
+type AuthProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// staticTokenProvider returns a fixed personal access token. This is the
+// original behavior of the service before GitHub App support was added.
+type staticTokenProvider struct {
+	token string
+}
+
+// NewStaticTokenProvider wraps a personal access token (or other long-lived
+// token) as an AuthProvider.
+func NewStaticTokenProvider(token string) AuthProvider {
+	return &staticTokenProvider{token: token}
+}
+
+func (p *staticTokenProvider) Token(ctx context.Context) (string, error) {
+	if p.token == "" {
+		return "", fmt.Errorf("no github token configured")
+	}
+	return p.token, nil
+}
+
+// GitHubAppAuthProvider authenticates as a GitHub App installation, minting
+// installation access tokens and caching them until shortly before expiry.
+type GitHubAppAuthProvider struct {
+	appID          int64
+	installationID int64
+	privateKey     *rsa.PrivateKey
+	apiURL         string
+	httpClient     *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewGitHubAppAuthProvider parses privateKeyPEM and returns an AuthProvider
+// that mints installation tokens for the given App ID / installation ID.
+func NewGitHubAppAuthProvider(apiURL string, appID, installationID int64, privateKeyPEM string) (*GitHubAppAuthProvider, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("parsing GitHub App private key: %w", err)
+	}
+
+	return &GitHubAppAuthProvider{
+		appID:          appID,
+		installationID: installationID,
+		privateKey:     key,
+		apiURL:         apiURL,
+		httpClient:     instrumentedHTTPClient(&http.Client{Timeout: 30 * time.Second}),
+	}, nil
+}
+
+// Token returns a cached installation token, minting a new one if the
+// cached one is missing or within a minute of expiring.
+func (p *GitHubAppAuthProvider) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && time.Now().Before(p.expiresAt.Add(-1*time.Minute)) {
+		return p.token, nil
+	}
+
+	appJWT, err := p.signAppJWT()
+	if err != nil {
+		return "", fmt.Errorf("signing app JWT: %w", err)
+	}
+
+	token, expiresAt, err := p.createInstallationToken(ctx, appJWT)
+	if err != nil {
+		return "", fmt.Errorf("creating installation token: %w", err)
+	}
+
+	p.token = token
+	p.expiresAt = expiresAt
+	return p.token, nil
+}
+
+// signAppJWT builds the short-lived JWT GitHub requires to identify the App
+// itself, as opposed to an installation, when minting installation tokens.
+func (p *GitHubAppAuthProvider) signAppJWT() (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now.Add(-30 * time.Second)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+		Issuer:    fmt.Sprintf("%d", p.appID),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(p.privateKey)
+}
+
+func (p *GitHubAppAuthProvider) createInstallationToken(ctx context.Context, appJWT string) (string, time.Time, error) {
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", p.apiURL, p.installationID)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+appJWT)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("requesting installation token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", time.Time{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return body.Token, body.ExpiresAt, nil
+}
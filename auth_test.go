@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testAppPrivateKeyPEM generates a fresh RSA key and returns it PEM-encoded,
+// in the PKCS#1 form GitHub Apps ship their private keys in.
+func testAppPrivateKeyPEM(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	block := &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}
+	return string(pem.EncodeToMemory(block)), key
+}
+
+func TestNewGitHubAppAuthProviderInvalidKey(t *testing.T) {
+	if _, err := NewGitHubAppAuthProvider("https://api.github.com", 1, 2, "not a pem key"); err == nil {
+		t.Error("NewGitHubAppAuthProvider() error = nil, want an error for an invalid private key")
+	}
+}
+
+func TestGitHubAppAuthProviderSignAppJWT(t *testing.T) {
+	keyPEM, key := testAppPrivateKeyPEM(t)
+	provider, err := NewGitHubAppAuthProvider("https://api.github.com", 42, 7, keyPEM)
+	if err != nil {
+		t.Fatalf("NewGitHubAppAuthProvider() error = %v", err)
+	}
+
+	tokenString, err := provider.signAppJWT()
+	if err != nil {
+		t.Fatalf("signAppJWT() error = %v", err)
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	parsed, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	})
+	if err != nil {
+		t.Fatalf("parsing signed JWT: %v", err)
+	}
+	if !parsed.Valid {
+		t.Fatal("signAppJWT() produced a JWT that didn't validate against its own public key")
+	}
+	if claims.Issuer != "42" {
+		t.Errorf("claims.Issuer = %q, want %q", claims.Issuer, "42")
+	}
+
+	lifetime := claims.ExpiresAt.Time.Sub(claims.IssuedAt.Time)
+	if lifetime <= 0 || lifetime > 10*time.Minute {
+		t.Errorf("claims lifetime = %v, want a short-lived token under GitHub's 10 minute cap", lifetime)
+	}
+	if claims.IssuedAt.Time.After(time.Now()) {
+		t.Error("claims.IssuedAt is in the future, want it backdated to tolerate clock drift")
+	}
+}
+
+func TestGitHubAppAuthProviderTokenMintsAndCaches(t *testing.T) {
+	keyPEM, _ := testAppPrivateKeyPEM(t)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got, want := r.URL.Path, "/app/installations/7/access_tokens"; got != want {
+			t.Errorf("request path = %q, want %q", got, want)
+		}
+		if got := r.Header.Get("Authorization"); got == "" {
+			t.Error("request missing Authorization header")
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":"installation-token-%d","expires_at":%q}`, requests, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	provider, err := NewGitHubAppAuthProvider(server.URL, 42, 7, keyPEM)
+	if err != nil {
+		t.Fatalf("NewGitHubAppAuthProvider() error = %v", err)
+	}
+
+	first, err := provider.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if first != "installation-token-1" {
+		t.Errorf("Token() = %q, want %q", first, "installation-token-1")
+	}
+
+	second, err := provider.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if second != first {
+		t.Errorf("Token() = %q on second call, want the cached %q (no new request expected)", second, first)
+	}
+	if requests != 1 {
+		t.Errorf("requests to the installation token endpoint = %d, want 1 (second Token() should have used the cache)", requests)
+	}
+}
+
+func TestGitHubAppAuthProviderTokenRefreshesWithinOneMinuteOfExpiry(t *testing.T) {
+	keyPEM, _ := testAppPrivateKeyPEM(t)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":"installation-token-%d","expires_at":%q}`, requests, time.Now().Add(30*time.Second).Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	provider, err := NewGitHubAppAuthProvider(server.URL, 42, 7, keyPEM)
+	if err != nil {
+		t.Fatalf("NewGitHubAppAuthProvider() error = %v", err)
+	}
+
+	if _, err := provider.Token(context.Background()); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("requests = %d, want 1 after the first Token() call", requests)
+	}
+
+	// The cached token expires in 30s, inside the 1 minute early-refresh
+	// window, so this call must mint a new one instead of reusing it.
+	if _, err := provider.Token(context.Background()); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2 (Token() should refresh a token within 1 minute of expiring)", requests)
+	}
+}
+
+func TestGitHubAppAuthProviderTokenPropagatesMintingError(t *testing.T) {
+	keyPEM, _ := testAppPrivateKeyPEM(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	provider, err := NewGitHubAppAuthProvider(server.URL, 42, 7, keyPEM)
+	if err != nil {
+		t.Fatalf("NewGitHubAppAuthProvider() error = %v", err)
+	}
+
+	if _, err := provider.Token(context.Background()); err == nil {
+		t.Error("Token() error = nil, want an error when the installation token endpoint rejects the request")
+	}
+}
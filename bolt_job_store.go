@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// jobsBucket holds every Job, keyed by ID, in a BoltJobStore.
+var jobsBucket = []byte("jobs")
+
+// BoltJobStore is a JobStore backed by a BoltDB file, so a process restart
+// (crash, deploy) doesn't drop jobs that are queued or mid-backoff.
+type BoltJobStore struct {
+	db *bbolt.DB
+}
+
+// NewBoltJobStore opens (creating if necessary) a BoltDB database at path,
+// ensures its jobs bucket exists, and requeues any job left in JobRunning by
+// a process that crashed or was killed mid-attempt, so it gets picked up
+// again instead of sitting there forever.
+func NewBoltJobStore(path string) (*BoltJobStore, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt db %s: %w", path, err)
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(jobsBucket)
+		if err != nil {
+			return err
+		}
+		return requeueOrphanedRunningJobs(bucket)
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("preparing jobs bucket in %s: %w", path, err)
+	}
+
+	return &BoltJobStore{db: db}, nil
+}
+
+// requeueOrphanedRunningJobs resets any job stuck in JobRunning back to
+// JobQueued so it is picked up by the next ClaimDue instead of being
+// abandoned. It runs once at startup: a clean process only ever sees its own
+// jobs move through JobRunning transiently inside attempt(), so anything
+// still JobRunning when the store is opened was orphaned by a crash.
+func requeueOrphanedRunningJobs(bucket *bbolt.Bucket) error {
+	var orphaned []Job
+	if err := bucket.ForEach(func(_, data []byte) error {
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return err
+		}
+		if job.State == JobRunning {
+			orphaned = append(orphaned, job)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, job := range orphaned {
+		job.State = JobQueued
+		job.NextAttempt = now
+		job.UpdatedAt = now
+		if err := putJobInBucket(bucket, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the underlying BoltDB file handle.
+func (s *BoltJobStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltJobStore) Save(job Job) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return putJob(tx, job)
+	})
+}
+
+func (s *BoltJobStore) Get(id string) (Job, error) {
+	var job Job
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(jobsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrJobNotFound
+		}
+		return json.Unmarshal(data, &job)
+	})
+	return job, err
+}
+
+func (s *BoltJobStore) List(state JobState) ([]Job, error) {
+	var jobs []Job
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(_, data []byte) error {
+			var job Job
+			if err := json.Unmarshal(data, &job); err != nil {
+				return err
+			}
+			if state == "" || job.State == state {
+				jobs = append(jobs, job)
+			}
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// ClaimDue finds due jobs and flips them to JobRunning within a single
+// read-write transaction, so two callers (e.g. a rolling deploy briefly
+// running two instances against the same file) can't both claim the same
+// job. Jobs are collected before being written back, since bbolt doesn't
+// allow mutating a bucket while ForEach is iterating it.
+func (s *BoltJobStore) ClaimDue(now time.Time) ([]Job, error) {
+	var claimed []Job
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(jobsBucket)
+
+		var due []Job
+		if err := bucket.ForEach(func(_, data []byte) error {
+			var job Job
+			if err := json.Unmarshal(data, &job); err != nil {
+				return err
+			}
+			if job.State == JobQueued && !job.NextAttempt.After(now) {
+				due = append(due, job)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, job := range due {
+			job.State = JobRunning
+			job.UpdatedAt = now
+			if err := putJob(tx, job); err != nil {
+				return err
+			}
+			claimed = append(claimed, job)
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// putJob marshals job and writes it into the jobs bucket within tx.
+func putJob(tx *bbolt.Tx, job Job) error {
+	return putJobInBucket(tx.Bucket(jobsBucket), job)
+}
+
+// putJobInBucket marshals job and writes it into bucket.
+func putJobInBucket(bucket *bbolt.Bucket, job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("marshaling job: %w", err)
+	}
+	return bucket.Put([]byte(job.ID), data)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoltJobStoreClaimDue(t *testing.T) {
+	store, err := NewBoltJobStore(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("NewBoltJobStore() error = %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	if err := store.Save(Job{ID: "job-1", State: JobQueued, NextAttempt: now.Add(-time.Second)}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Save(Job{ID: "job-2", State: JobQueued, NextAttempt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	claimed, err := store.ClaimDue(now)
+	if err != nil {
+		t.Fatalf("ClaimDue() error = %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != "job-1" || claimed[0].State != JobRunning {
+		t.Fatalf("ClaimDue() = %+v, want one claimed, running job-1", claimed)
+	}
+
+	again, err := store.ClaimDue(now)
+	if err != nil {
+		t.Fatalf("ClaimDue() error = %v", err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("ClaimDue() re-claimed an already-running job: %+v", again)
+	}
+
+	got, err := store.Get("job-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.State != JobRunning {
+		t.Errorf("Get() state = %v, want %v", got.State, JobRunning)
+	}
+}
+
+func TestNewBoltJobStoreRequeuesOrphanedRunningJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+
+	store, err := NewBoltJobStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltJobStore() error = %v", err)
+	}
+	if err := store.Save(Job{ID: "job-1", State: JobRunning, Attempts: 1}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Simulate the process restarting after a crash mid-attempt.
+	reopened, err := NewBoltJobStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltJobStore() (reopen) error = %v", err)
+	}
+	defer reopened.Close()
+
+	got, err := reopened.Get("job-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.State != JobQueued {
+		t.Errorf("Get() state = %v, want %v (requeued after crash)", got.State, JobQueued)
+	}
+
+	claimed, err := reopened.ClaimDue(time.Now())
+	if err != nil {
+		t.Fatalf("ClaimDue() error = %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != "job-1" {
+		t.Fatalf("ClaimDue() = %+v, want the requeued job to be claimable", claimed)
+	}
+}
+
+func TestBoltJobStoreGetNotFound(t *testing.T) {
+	store, err := NewBoltJobStore(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("NewBoltJobStore() error = %v", err)
+	}
+	defer store.Close()
+
+	if _, err := store.Get("missing"); err != ErrJobNotFound {
+		t.Errorf("Get() error = %v, want ErrJobNotFound", err)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// JobState is the lifecycle state of a dispatch job.
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+// Job is one dispatch request working its way through the Dispatcher.
+type Job struct {
+	ID          string    `json:"id"`
+	Application string    `json:"application"`
+	GitHubToken string    `json:"-"`
+	RequestID   string    `json:"requestId,omitempty"`
+	State       JobState  `json:"state"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextAttempt,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+	CommitHash  string    `json:"commitHash,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// ErrJobNotFound is returned by JobStore.Get when no job has the given ID.
+var ErrJobNotFound = errors.New("job not found")
+
+// JobStore persists Jobs for the Dispatcher. Implementations must be safe
+// for concurrent use. In-memory and BoltDB (single-node durability) are
+// shipped today; Redis (HA) can implement the same interface without the
+// Dispatcher knowing the difference.
+type JobStore interface {
+	Save(job Job) error
+	Get(id string) (Job, error)
+	List(state JobState) ([]Job, error)
+	// ClaimDue atomically transitions queued jobs whose NextAttempt has
+	// passed to JobRunning and returns them. Implementations must make this
+	// claim atomic (e.g. under the same lock/transaction that reads it), so
+	// that two workers racing the same tick never both claim the same job.
+	ClaimDue(now time.Time) ([]Job, error)
+}
+
+// NewJobStore builds a JobStore for the given backend name. boltPath is the
+// database file used by the "bolt" backend; it is ignored otherwise.
+func NewJobStore(backend, boltPath string) (JobStore, error) {
+	switch backend {
+	case "", "memory":
+		return NewInMemoryJobStore(), nil
+	case "bolt":
+		return NewBoltJobStore(boltPath)
+	default:
+		return nil, fmt.Errorf("unknown job queue backend %q", backend)
+	}
+}
+
+// InMemoryJobStore is a JobStore backed by a mutex-guarded map. Jobs are
+// lost on restart; use the "bolt" backend if a crash shouldn't drop queued
+// jobs (GitHub webhook retries make this optional, not required).
+type InMemoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+// NewInMemoryJobStore creates an empty InMemoryJobStore.
+func NewInMemoryJobStore() *InMemoryJobStore {
+	return &InMemoryJobStore{jobs: make(map[string]Job)}
+}
+
+func (s *InMemoryJobStore) Save(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *InMemoryJobStore) Get(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (s *InMemoryJobStore) List(state JobState) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if state == "" || job.State == state {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *InMemoryJobStore) ClaimDue(now time.Time) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var claimed []Job
+	for id, job := range s.jobs {
+		if job.State == JobQueued && !job.NextAttempt.After(now) {
+			job.State = JobRunning
+			job.UpdatedAt = now
+			s.jobs[id] = job
+			claimed = append(claimed, job)
+		}
+	}
+	return claimed, nil
+}
+
+// Dispatcher enqueues dispatch requests and processes them in a worker
+// pool with exponential backoff, so a transient GitHub 5xx or rate-limit
+// doesn't drop the event the way a synchronous handler would. It dedupes
+// by (application, commitHash) so repeat webhook deliveries for the same
+// commit don't fire multiple environment_ready events.
+type Dispatcher struct {
+	server      *Server
+	store       JobStore
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+
+	lastJobID int64
+
+	dispatchedMu sync.Mutex
+	dispatched   map[string]bool // dedup key: application + "@" + commitHash
+}
+
+// NewDispatcher builds a Dispatcher that persists jobs to store and retries
+// failed attempts up to maxAttempts times. It seeds its job ID counter from
+// the highest ID already in store, so that a restart against a durable
+// backend (e.g. "bolt") doesn't start handing out IDs from job-1 again and
+// overwrite history still sitting in the store.
+func NewDispatcher(server *Server, store JobStore, maxAttempts int) (*Dispatcher, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	lastJobID, err := highestJobID(store)
+	if err != nil {
+		return nil, fmt.Errorf("determining last job ID: %w", err)
+	}
+	return &Dispatcher{
+		server:      server,
+		store:       store,
+		maxAttempts: maxAttempts,
+		minBackoff:  time.Second,
+		maxBackoff:  5 * time.Minute,
+		lastJobID:   lastJobID,
+		dispatched:  make(map[string]bool),
+	}, nil
+}
+
+// highestJobID returns the largest numeric suffix among IDs already in
+// store, formatted as "job-<n>" by Enqueue. Non-conforming IDs are ignored
+// rather than treated as an error, so the store can't be poisoned into
+// making NewDispatcher fail.
+func highestJobID(store JobStore) (int64, error) {
+	jobs, err := store.List("")
+	if err != nil {
+		return 0, err
+	}
+	var highest int64
+	for _, job := range jobs {
+		n, err := strconv.ParseInt(strings.TrimPrefix(job.ID, "job-"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if n > highest {
+			highest = n
+		}
+	}
+	return highest, nil
+}
+
+// Enqueue records a new job and returns it immediately; the caller doesn't
+// wait for the dispatch to actually happen. requestID is carried through to
+// the job's log lines so it can be correlated with the request that
+// triggered it.
+func (d *Dispatcher) Enqueue(applicationName, githubToken, requestID string) (Job, error) {
+	now := time.Now()
+	job := Job{
+		ID:          fmt.Sprintf("job-%d", atomic.AddInt64(&d.lastJobID, 1)),
+		Application: applicationName,
+		GitHubToken: githubToken,
+		RequestID:   requestID,
+		State:       JobQueued,
+		NextAttempt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := d.store.Save(job); err != nil {
+		return Job{}, fmt.Errorf("saving job: %w", err)
+	}
+	return job, nil
+}
+
+// Run starts workers worker goroutines that poll the store for due jobs
+// until ctx is canceled.
+func (d *Dispatcher) Run(ctx context.Context, workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go d.worker(ctx)
+	}
+}
+
+func (d *Dispatcher) worker(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.processDue(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) processDue(ctx context.Context) {
+	claimed, err := d.store.ClaimDue(time.Now())
+	if err != nil {
+		slog.Default().Error("claiming due jobs", "error", err)
+		return
+	}
+
+	for _, job := range claimed {
+		d.attempt(ctx, job)
+	}
+}
+
+// attempt runs a single attempt at dispatching job, advancing its state and
+// scheduling a retry with backoff on a transient failure. job must already
+// have been claimed (moved out of JobQueued) by the caller via
+// JobStore.ClaimDue, so concurrent workers never run the same attempt twice.
+func (d *Dispatcher) attempt(ctx context.Context, job Job) {
+	ctx = contextWithRequestID(ctx, job.RequestID)
+	logger := requestLogger(ctx).With("application", job.Application, "job_id", job.ID)
+
+	job.State = JobRunning
+	job.Attempts++
+	job.UpdatedAt = time.Now()
+	if err := d.store.Save(job); err != nil {
+		logger.Error("saving job", "error", err)
+		return
+	}
+
+	start := time.Now()
+	commitHash, err := d.server.resolveCommitHash(ctx, job.Application)
+	fetchValuesDuration.Observe(time.Since(start).Seconds())
+	logger = logger.With("commit_hash", commitHash)
+	if err == nil {
+		var alreadyDispatched bool
+		alreadyDispatched, err = d.isAlreadyDispatched(job.Application, commitHash)
+		if err == nil {
+			if alreadyDispatched {
+				logger.Info("commit already dispatched, skipping")
+			} else {
+				start = time.Now()
+				err = d.server.sendGitHubDispatch(ctx, job.GitHubToken, commitHash, job.Application)
+				dispatchDuration.Observe(time.Since(start).Seconds())
+				if err == nil {
+					d.recordDispatched(job.Application, commitHash)
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		job.LastError = err.Error()
+		job.UpdatedAt = time.Now()
+		if job.Attempts >= d.maxAttempts || !isRetryable(err) {
+			job.State = JobFailed
+			logger.Error("job failed permanently", "attempts", job.Attempts, "error", err)
+			dispatchRequestsTotal.WithLabelValues("failed", "max_attempts").Inc()
+		} else {
+			job.State = JobQueued
+			job.NextAttempt = time.Now().Add(backoffDelay(job.Attempts, d.minBackoff, d.maxBackoff))
+			logger.Warn("job attempt failed, retrying", "attempts", job.Attempts, "next_attempt", job.NextAttempt.Format(time.RFC3339), "error", err)
+		}
+		if saveErr := d.store.Save(job); saveErr != nil {
+			logger.Error("saving job", "error", saveErr)
+		}
+		return
+	}
+
+	job.State = JobSucceeded
+	job.CommitHash = commitHash
+	job.UpdatedAt = time.Now()
+	if err := d.store.Save(job); err != nil {
+		logger.Error("saving job", "error", err)
+		return
+	}
+	dispatchRequestsTotal.WithLabelValues("succeeded", "").Inc()
+	logger.Info("job succeeded")
+}
+
+// isAlreadyDispatched reports whether (application, commitHash) was already
+// successfully dispatched. The in-memory map alone would forget every dedup
+// decision across a restart, and a job that was requeued after a crash (see
+// requeueOrphanedRunningJobs) could then be re-dispatched even though it had
+// already gone out before the crash; so a miss in the map falls back to
+// checking the store for a prior JobSucceeded job with the same
+// (application, commitHash), which survives restarts.
+func (d *Dispatcher) isAlreadyDispatched(application, commitHash string) (bool, error) {
+	key := application + "@" + commitHash
+
+	d.dispatchedMu.Lock()
+	if d.dispatched[key] {
+		d.dispatchedMu.Unlock()
+		return true, nil
+	}
+	d.dispatchedMu.Unlock()
+
+	alreadyDispatched, err := d.alreadyDispatchedInStore(application, commitHash)
+	if err != nil {
+		return false, fmt.Errorf("checking prior dispatch: %w", err)
+	}
+	return alreadyDispatched, nil
+}
+
+// recordDispatched records (application, commitHash) as dispatched, so a
+// concurrent or later attempt for the same commit is recognized as a
+// duplicate without hitting the store. Callers must only call this after
+// sendGitHubDispatch has actually succeeded: marking it beforehand would
+// turn a transient send failure into a permanently skipped dispatch, since
+// every retry would then see the key already marked.
+func (d *Dispatcher) recordDispatched(application, commitHash string) {
+	key := application + "@" + commitHash
+
+	d.dispatchedMu.Lock()
+	defer d.dispatchedMu.Unlock()
+	d.dispatched[key] = true
+}
+
+// alreadyDispatchedInStore reports whether the store already holds a
+// JobSucceeded job for (application, commitHash).
+func (d *Dispatcher) alreadyDispatchedInStore(application, commitHash string) (bool, error) {
+	succeeded, err := d.store.List(JobSucceeded)
+	if err != nil {
+		return false, err
+	}
+	for _, job := range succeeded {
+		if job.Application == application && job.CommitHash == commitHash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// backoffDelay returns an exponential backoff delay for the given attempt
+// number (1-indexed): min, 2*min, 4*min, ... capped at max.
+func backoffDelay(attempt int, min, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := min << uint(attempt-1)
+	if delay <= 0 || delay > max {
+		return max
+	}
+	return delay
+}
+
+// isRetryable reports whether err looks like a transient GitHub failure
+// (5xx, or a primary/secondary rate limit) worth retrying.
+func isRetryable(err error) bool {
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return true
+	}
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		return true
+	}
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		return ghErr.Response.StatusCode >= 500 || ghErr.Response.StatusCode == http.StatusForbidden
+	}
+	return false
+}
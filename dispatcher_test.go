@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	min := time.Second
+	max := 5 * time.Minute
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Second},
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: 2 * time.Second},
+		{attempt: 3, want: 4 * time.Second},
+		{attempt: 4, want: 8 * time.Second},
+		{attempt: 20, want: max},
+	}
+
+	for _, tt := range tests {
+		if got := backoffDelay(tt.attempt, min, max); got != tt.want {
+			t.Errorf("backoffDelay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil error", nil, false},
+		{"plain error", errors.New("boom"), false},
+		{"rate limit error", &github.RateLimitError{}, true},
+		{"abuse rate limit error", &github.AbuseRateLimitError{}, true},
+		{
+			name: "5xx response",
+			err: &github.ErrorResponse{
+				Response: &http.Response{StatusCode: http.StatusBadGateway},
+			},
+			want: true,
+		},
+		{
+			name: "403 forbidden",
+			err: &github.ErrorResponse{
+				Response: &http.Response{StatusCode: http.StatusForbidden},
+			},
+			want: true,
+		},
+		{
+			name: "404 not found",
+			err: &github.ErrorResponse{
+				Response: &http.Response{StatusCode: http.StatusNotFound},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDispatcherSeedsLastJobIDFromStore(t *testing.T) {
+	store := NewInMemoryJobStore()
+	if err := store.Save(Job{ID: "job-1", State: JobSucceeded}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Save(Job{ID: "job-7", State: JobSucceeded}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Save(Job{ID: "job-3", State: JobFailed}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	d, err := NewDispatcher(&Server{}, store, 0)
+	if err != nil {
+		t.Fatalf("NewDispatcher() error = %v", err)
+	}
+
+	job, err := d.Enqueue("pr-1", "", "")
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if job.ID != "job-8" {
+		t.Errorf("Enqueue() ID = %q, want job-8 (after the highest existing ID, job-7)", job.ID)
+	}
+}
+
+func TestIsAlreadyDispatchedFallsBackToStoreAfterRestart(t *testing.T) {
+	store := NewInMemoryJobStore()
+	if err := store.Save(Job{ID: "job-1", Application: "pr-1", CommitHash: "abc123", State: JobSucceeded}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A fresh Dispatcher simulates a restart: its in-memory dedup map is
+	// empty, but the store still remembers the prior successful dispatch.
+	d, err := NewDispatcher(&Server{}, store, 0)
+	if err != nil {
+		t.Fatalf("NewDispatcher() error = %v", err)
+	}
+
+	alreadyDispatched, err := d.isAlreadyDispatched("pr-1", "abc123")
+	if err != nil {
+		t.Fatalf("isAlreadyDispatched() error = %v", err)
+	}
+	if !alreadyDispatched {
+		t.Error("isAlreadyDispatched() = false, want true for a commit already recorded as succeeded in the store")
+	}
+
+	alreadyDispatched, err = d.isAlreadyDispatched("pr-1", "def456")
+	if err != nil {
+		t.Fatalf("isAlreadyDispatched() error = %v", err)
+	}
+	if alreadyDispatched {
+		t.Error("isAlreadyDispatched() = true, want false for a commit never dispatched")
+	}
+}
+
+func TestIsAlreadyDispatchedNotMarkedBeforeSendSucceeds(t *testing.T) {
+	store := NewInMemoryJobStore()
+	d, err := NewDispatcher(&Server{}, store, 0)
+	if err != nil {
+		t.Fatalf("NewDispatcher() error = %v", err)
+	}
+
+	// Checking must not itself record anything: a transient send failure
+	// after the check has to leave the next retry free to try again.
+	alreadyDispatched, err := d.isAlreadyDispatched("pr-1", "abc123")
+	if err != nil {
+		t.Fatalf("isAlreadyDispatched() error = %v", err)
+	}
+	if alreadyDispatched {
+		t.Fatal("isAlreadyDispatched() = true on first check, want false")
+	}
+	alreadyDispatched, err = d.isAlreadyDispatched("pr-1", "abc123")
+	if err != nil {
+		t.Fatalf("isAlreadyDispatched() error = %v", err)
+	}
+	if alreadyDispatched {
+		t.Fatal("isAlreadyDispatched() = true after a failed send was never recorded, want false so the retry isn't skipped")
+	}
+
+	d.recordDispatched("pr-1", "abc123")
+	alreadyDispatched, err = d.isAlreadyDispatched("pr-1", "abc123")
+	if err != nil {
+		t.Fatalf("isAlreadyDispatched() error = %v", err)
+	}
+	if !alreadyDispatched {
+		t.Error("isAlreadyDispatched() = false after recordDispatched, want true")
+	}
+}
+
+func TestInMemoryJobStoreClaimDueDoesNotDoubleClaim(t *testing.T) {
+	store := NewInMemoryJobStore()
+	now := time.Now()
+	if err := store.Save(Job{ID: "job-1", State: JobQueued, NextAttempt: now.Add(-time.Second)}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	first, err := store.ClaimDue(now)
+	if err != nil {
+		t.Fatalf("ClaimDue() error = %v", err)
+	}
+	if len(first) != 1 || first[0].State != JobRunning {
+		t.Fatalf("ClaimDue() = %+v, want one claimed, running job", first)
+	}
+
+	second, err := store.ClaimDue(now)
+	if err != nil {
+		t.Fatalf("ClaimDue() error = %v", err)
+	}
+	if len(second) != 0 {
+		t.Fatalf("ClaimDue() re-claimed an already-running job: %+v", second)
+	}
+}
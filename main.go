@@ -1,18 +1,24 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/google/go-github/v57/github"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/oauth2"
 )
 
 // IncomingPayload represents the incoming request structure
@@ -21,21 +27,49 @@ type IncomingPayload struct {
 	GithubToken string `json:"github_token"`
 }
 
-// ValuesYAML represents the structure of the values.yaml file
-type ValuesYAML struct {
-	Global struct {
-		Config struct {
-			DeploymentTag string `yaml:"DEPLOYMENT_TAG"`
-		} `yaml:"config"`
-	} `yaml:"global"`
+// PullRequestEvent is the subset of the GitHub `pull_request` webhook
+// payload needed to derive the application being deployed.
+type PullRequestEvent struct {
+	Action     string `json:"action"`
+	Number     int    `json:"number"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
 }
 
-// GitHubDispatchPayload represents the payload sent to GitHub
-type GitHubDispatchPayload struct {
-	EventType     string                `json:"event_type"`
-	ClientPayload DispatchClientPayload `json:"client_payload"`
+// WorkflowRunEvent is the subset of the GitHub `workflow_run` webhook
+// payload needed to derive the application being deployed.
+type WorkflowRunEvent struct {
+	WorkflowRun struct {
+		Status       string `json:"status"`
+		Conclusion   string `json:"conclusion"`
+		PullRequests []struct {
+			Number int `json:"number"`
+		} `json:"pull_requests"`
+	} `json:"workflow_run"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
 }
 
+// DeploymentStatusEvent is the subset of the GitHub `deployment_status`
+// webhook payload needed to derive the application being deployed. The
+// status lives in its own `deployment_status` object, separate from the
+// `deployment` it's about.
+type DeploymentStatusEvent struct {
+	DeploymentStatus struct {
+		State string `json:"state"`
+	} `json:"deployment_status"`
+	Deployment struct {
+		Environment string `json:"environment"`
+	} `json:"deployment"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// DispatchClientPayload is the `client_payload` sent along with the
+// `environment_ready` repository_dispatch event.
 type DispatchClientPayload struct {
 	CommitHash string `json:"commitHash"`
 	SourceName string `json:"sourceName"`
@@ -43,148 +77,204 @@ type DispatchClientPayload struct {
 
 // Config holds the application configuration
 type Config struct {
-	Port              string
-	GitHubAPIURL      string
-	TargetRepo        string
-	DeploymentRepo    string
-	GitHubToken       string // Optional: for private repos
+	Port                    string
+	GitHubAPIURL            string
+	TargetRepo              string
+	DeploymentRepo          string
+	GitHubToken             string // Optional: for private repos, used if no GitHub App is configured
+	WebhookSecret           string // Optional: required to accept requests on /webhook
+	GitHubAppID             int64  // Optional: enables GitHub App installation auth
+	GitHubAppPrivateKey     string // PEM-encoded private key for the App above
+	GitHubAppInstallationID int64
+	DeploymentSourceKind    string // "github" (default), "gitlab", "gitea", or "local"
+	DeploymentSourceBaseURL string // Optional: API/host override for the kind above
+	DeploymentBranch        string // Branch to read DeploymentRepo from, unless overridden by PkgdashConfigPath
+	PkgdashConfigPath       string // Path to a pkgdash.yml with path templates and tag selector
+	JobQueueBackend         string // "memory" (default), "bolt"; SQLite/Redis backends can implement JobStore
+	JobQueueBoltPath        string // BoltDB file path, used when JobQueueBackend is "bolt"
+	JobWorkers              int    // Number of worker goroutines polling the job queue
+	JobMaxAttempts          int    // Attempts before a job is marked failed instead of retried
 }
 
 // Server holds the server dependencies
 type Server struct {
-	config     Config
-	httpClient *http.Client
+	config         Config
+	httpClient     *http.Client
+	authProvider   AuthProvider
+	sourceProvider SourceProvider
+	pkgdashConfig  PkgdashConfig
+	dispatcher     *Dispatcher
 }
 
-// NewServer creates a new server instance
-func NewServer(config Config) *Server {
-	return &Server{
+// NewServer creates a new server instance. If config has a GitHubAppID set,
+// requests are authenticated as that App's installation; otherwise they fall
+// back to config.GitHubToken (or a per-request token in IncomingPayload).
+func NewServer(config Config) (*Server, error) {
+	server := &Server{
 		config: config,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
-}
 
-// fetchValuesFile fetches the values.yaml file from GitHub
-func (s *Server) fetchValuesFile(ctx context.Context, path string, githubToken string) ([]byte, error) {
-	// Construct the raw content URL for GitHub
-	// Format: https://raw.githubusercontent.com/{owner}/{repo}/{branch}/{path}
-	parts := strings.Split(s.config.DeploymentRepo, "/")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid repository URL format")
+	if config.GitHubAppID != 0 {
+		appAuth, err := NewGitHubAppAuthProvider(config.GitHubAPIURL, config.GitHubAppID, config.GitHubAppInstallationID, config.GitHubAppPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("configuring GitHub App auth: %w", err)
+		}
+		server.authProvider = appAuth
+	} else {
+		server.authProvider = NewStaticTokenProvider(config.GitHubToken)
 	}
-	
-	owner := parts[0]
-	repo := parts[1]
-	
-	url := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/master/%s",	owner, repo, path)
-	
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+
+	pkgdashConfig, err := LoadPkgdashConfig(config.PkgdashConfigPath)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, fmt.Errorf("loading pkgdash config: %w", err)
 	}
-	
-	// Authorization token is required for private repos
-	if githubToken != "" {
-		req.Header.Set("Authorization", "token "+githubToken)
-	} else {
-		return nil, fmt.Errorf("no github token provided")
+	if pkgdashConfig.Branch == "" {
+		pkgdashConfig.Branch = config.DeploymentBranch
+	}
+	server.pkgdashConfig = pkgdashConfig
+
+	sourceBaseURL := config.DeploymentSourceBaseURL
+	if sourceBaseURL == "" && (config.DeploymentSourceKind == "" || config.DeploymentSourceKind == "github") {
+		sourceBaseURL = config.GitHubAPIURL
 	}
-	
-	resp, err := s.httpClient.Do(req)
+	sourceProvider, err := NewSourceProvider(config.DeploymentSourceKind, config.DeploymentRepo, pkgdashConfig.Branch, sourceBaseURL, server.authProvider, server.httpClient)
 	if err != nil {
-		return nil, fmt.Errorf("fetching file: %w", err)
+		return nil, fmt.Errorf("configuring deployment source: %w", err)
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("file not found at %s", path)
+	server.sourceProvider = sourceProvider
+
+	jobStore, err := NewJobStore(config.JobQueueBackend, config.JobQueueBoltPath)
+	if err != nil {
+		return nil, fmt.Errorf("configuring job queue: %w", err)
 	}
-	
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	dispatcher, err := NewDispatcher(server, jobStore, config.JobMaxAttempts)
+	if err != nil {
+		return nil, fmt.Errorf("configuring dispatcher: %w", err)
 	}
-	
-	return io.ReadAll(resp.Body)
+	server.dispatcher = dispatcher
+
+	return server, nil
 }
 
-// extractDeploymentTag extracts the DEPLOYMENT_TAG from values.yaml
-func (s *Server) extractDeploymentTag(content []byte) (string, error) {
-	var values ValuesYAML
-	
-	if err := yaml.Unmarshal(content, &values); err != nil {
-		return "", fmt.Errorf("parsing YAML: %w", err)
+// resolveGitHubToken returns payloadToken if the caller supplied one, or
+// falls back to the server's configured AuthProvider.
+func (s *Server) resolveGitHubToken(ctx context.Context, payloadToken string) (string, error) {
+	if payloadToken != "" {
+		return payloadToken, nil
 	}
-	
-	deploymentTag := values.Global.Config.DeploymentTag
-	if deploymentTag == "" {
-		return "", fmt.Errorf("DEPLOYMENT_TAG is empty or not found")
+	return s.authProvider.Token(ctx)
+}
+
+// githubClient builds a go-github client authenticated with token, pointed
+// at the configured GitHub API URL.
+func (s *Server) githubClient(ctx context.Context, token string) (*github.Client, error) {
+	ctx = withInstrumentedHTTPClient(ctx, s.httpClient)
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	client := github.NewClient(oauth2.NewClient(ctx, ts))
+
+	if s.config.GitHubAPIURL != "" && s.config.GitHubAPIURL != "https://api.github.com" {
+		return client.WithEnterpriseURLs(s.config.GitHubAPIURL, s.config.GitHubAPIURL)
 	}
-	
-	return deploymentTag, nil
+	return client, nil
 }
 
-// sendGitHubDispatch sends a repository dispatch event to GitHub
+// sendGitHubDispatch sends a repository_dispatch event to the target repo.
 func (s *Server) sendGitHubDispatch(ctx context.Context, githubToken, commitHash, sourceName string) error {
-	payload := GitHubDispatchPayload{
-		EventType: "environment_ready",
-		ClientPayload: DispatchClientPayload{
-			CommitHash: commitHash,
-			SourceName: sourceName,
-		},	
-	}
-	
-	jsonPayload, err := json.Marshal(payload)
+	parts := strings.Split(s.config.TargetRepo, "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid target repository format")
+	}
+	owner := parts[0]
+	repo := parts[1]
+
+	clientPayload, err := json.Marshal(DispatchClientPayload{
+		CommitHash: commitHash,
+		SourceName: sourceName,
+	})
 	if err != nil {
 		return fmt.Errorf("marshaling payload: %w", err)
 	}
-	
-	url := fmt.Sprintf("%s/repos/%s/dispatches", s.config.GitHubAPIURL, s.config.TargetRepo)
-	
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonPayload))
+	rawClientPayload := json.RawMessage(clientPayload)
+
+	client, err := s.githubClient(ctx, githubToken)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
-	}
-	
-	req.Header.Set("Authorization", "token "+githubToken)
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := s.httpClient.Do(req)
+		return fmt.Errorf("building GitHub client: %w", err)
+	}
+
+	_, _, err = client.Repositories.Dispatch(ctx, owner, repo, github.DispatchRequestOptions{
+		EventType:     "environment_ready",
+		ClientPayload: &rawClientPayload,
+	})
 	if err != nil {
 		return fmt.Errorf("sending dispatch: %w", err)
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
-	}
-	
+
 	return nil
 }
 
+// resolveCommitHash looks up the values file for applicationName and
+// extracts its deployment tag. It is the part of the dispatch pipeline the
+// Dispatcher needs to run before it can dedupe by (application, commitHash)
+// and decide whether to actually send a repository_dispatch event.
+func (s *Server) resolveCommitHash(ctx context.Context, applicationName string) (string, error) {
+	paths, err := s.pkgdashConfig.RenderPaths(applicationName)
+	if err != nil {
+		return "", fmt.Errorf("rendering path templates: %w", err)
+	}
+
+	var valuesContent []byte
+	var foundPath string
+	for _, path := range paths {
+		valuesContent, err = s.sourceProvider.FetchFile(ctx, path)
+		if err == nil {
+			foundPath = path
+			break
+		}
+	}
+
+	if foundPath == "" {
+		return "", fmt.Errorf("could not find a values file for application '%s'", applicationName)
+	}
+	requestLogger(ctx).Info("found values file", "application", applicationName, "path", foundPath)
+
+	commitHash, err := s.pkgdashConfig.ExtractTag(valuesContent)
+	if err != nil {
+		return "", fmt.Errorf("extracting deployment tag: %w", err)
+	}
+	requestLogger(ctx).Info("extracted commit hash", "application", applicationName, "commit_hash", commitHash)
+
+	return commitHash, nil
+}
+
 // handleDispatch handles the `/dispatch` endpoint
 func (s *Server) handleDispatch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
+
+	ctx := r.Context()
+	logger := requestLogger(ctx)
+
 	// Parse request body
 	var payload IncomingPayload
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		log.Printf("Error decoding payload: %v", err)
+		logger.Error("decoding payload", "error", err)
+		dispatchRequestsTotal.WithLabelValues("rejected", "invalid_payload").Inc()
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
-	
+
 	applicationName := payload.Application
-	
+	logger = logger.With("application", applicationName)
+
 	// Check if application name starts with 'pr-'
 	if !strings.HasPrefix(applicationName, "pr-") {
-		log.Printf("Skipping application '%s' - doesn't start with 'pr-'", applicationName)
+		logger.Info("skipping application - doesn't start with 'pr-'")
+		dispatchRequestsTotal.WithLabelValues("skipped", "not_a_pr").Inc()
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{
 			"status": "skipped",
@@ -193,71 +283,195 @@ func (s *Server) handleDispatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Dispatching for application '%s'", applicationName)
-	
-	ctx := r.Context()
-	
-	// Try to find values.yaml in light or heavy path
-	paths := []string{
-		fmt.Sprintf("configs/pr/light/%s/values.yaml", applicationName),
-		fmt.Sprintf("configs/pr/heavy/%s/values.yaml", applicationName),
-	}
-	
-	var githubToken string
-	if s.config.GitHubToken != "" {
-		githubToken = s.config.GitHubToken
-	} else if payload.GithubToken != "" {
-		githubToken = payload.GithubToken
-	} else {
+	githubToken, err := s.resolveGitHubToken(ctx, payload.GithubToken)
+	if err != nil {
+		logger.Error("resolving GitHub token", "error", err)
+		dispatchRequestsTotal.WithLabelValues("rejected", "missing_token").Inc()
 		http.Error(w, "A GitHub token is required", http.StatusBadRequest)
 		return
 	}
 
-	var valuesContent []byte
-	var foundPath string
-	var err error
-	for _, path := range paths {
-		valuesContent, err = s.fetchValuesFile(ctx, path, githubToken)
-		if err == nil {
-			foundPath = path
-			break
+	job, err := s.dispatcher.Enqueue(applicationName, githubToken, requestIDFromContext(ctx))
+	if err != nil {
+		logger.Error("enqueueing job", "error", err)
+		dispatchRequestsTotal.WithLabelValues("rejected", "enqueue_failed").Inc()
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("enqueued job", "job_id", job.ID)
+	dispatchRequestsTotal.WithLabelValues("accepted", "").Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "accepted",
+		"jobId":      job.ID,
+		"sourceName": applicationName,
+	})
+}
+
+// verifyWebhookSignature checks the `X-Hub-Signature-256` header against an
+// HMAC-SHA256 digest of body computed with the configured WebhookSecret.
+func verifyWebhookSignature(secret string, body []byte, signatureHeader string) bool {
+	if signatureHeader == "" {
+		return false
+	}
+
+	const prefix = "sha256="
+	if !strings.HasPrefix(signatureHeader, prefix) {
+		return false
+	}
+
+	expectedMAC := hmac.New(sha256.New, []byte(secret))
+	expectedMAC.Write(body)
+	expectedSignature := hex.EncodeToString(expectedMAC.Sum(nil))
+
+	return hmac.Equal([]byte(signatureHeader[len(prefix):]), []byte(expectedSignature))
+}
+
+// pullRequestActionsToDeploy are the `pull_request` actions that mean "there
+// is a new commit to deploy a preview for". Actions like "closed", "labeled",
+// or "assigned" don't change what should be deployed.
+var pullRequestActionsToDeploy = map[string]bool{
+	"opened":      true,
+	"reopened":    true,
+	"synchronize": true,
+}
+
+// applicationFromWebhookEvent derives the `pr-<number>` application name from
+// one of the supported GitHub event payloads. ok is false if the event
+// doesn't map to a pull request we care about (e.g. a deployment_status for
+// an environment that isn't a PR preview), or if it doesn't represent a
+// successful, completed state worth dispatching for (e.g. a deployment
+// that's still pending, or a workflow_run that's still in progress).
+func applicationFromWebhookEvent(eventType string, body []byte) (applicationName string, ok bool, err error) {
+	switch eventType {
+	case "ping":
+		// Sent by GitHub when the webhook is first configured (and on a
+		// manual "Redeliver"), purely to check connectivity.
+		return "", false, nil
+
+	case "pull_request":
+		var event PullRequestEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return "", false, fmt.Errorf("parsing pull_request event: %w", err)
+		}
+		if !pullRequestActionsToDeploy[event.Action] {
+			return "", false, nil
+		}
+		return fmt.Sprintf("pr-%d", event.Number), true, nil
+
+	case "workflow_run":
+		var event WorkflowRunEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return "", false, fmt.Errorf("parsing workflow_run event: %w", err)
 		}
+		if event.WorkflowRun.Status != "completed" || event.WorkflowRun.Conclusion != "success" {
+			return "", false, nil
+		}
+		if len(event.WorkflowRun.PullRequests) == 0 {
+			return "", false, nil
+		}
+		return fmt.Sprintf("pr-%d", event.WorkflowRun.PullRequests[0].Number), true, nil
+
+	case "deployment_status":
+		var event DeploymentStatusEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return "", false, fmt.Errorf("parsing deployment_status event: %w", err)
+		}
+		if event.DeploymentStatus.State != "success" {
+			return "", false, nil
+		}
+		if !strings.HasPrefix(event.Deployment.Environment, "pr-") {
+			return "", false, nil
+		}
+		return event.Deployment.Environment, true, nil
+
+	default:
+		return "", false, fmt.Errorf("unsupported event type '%s'", eventType)
 	}
-	
-	if foundPath == "" {
-		log.Printf("Could not find 'values.yaml' for application '%s'", applicationName)
-		http.Error(w, fmt.Sprintf("Could not find 'values.yaml' for application '%s'", applicationName), http.StatusNotFound)
+}
+
+// handleWebhook handles the `/webhook` endpoint, allowing the service to be
+// wired directly into a GitHub App or repository webhook instead of requiring
+// a separate caller to post an IncomingPayload to `/dispatch`.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	log.Printf("Found values file at: %s", foundPath)
-	
-	commitHash, err := s.extractDeploymentTag(valuesContent)
+
+	ctx := r.Context()
+	logger := requestLogger(ctx)
+
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error extracting deployment tag: %v", err)
-		http.Error(w, fmt.Sprintf("Error extracting deployment tag: %v", err), http.StatusInternalServerError)
+		logger.Error("reading webhook body", "error", err)
+		dispatchRequestsTotal.WithLabelValues("rejected", "invalid_payload").Inc()
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
-	log.Printf("Extracted SC commit hash: %s", commitHash)
-	
-	if err := s.sendGitHubDispatch(ctx, githubToken, commitHash, applicationName); err != nil {
-		log.Printf("Error sending dispatch: %v", err)
-		http.Error(w, fmt.Sprintf("Error sending dispatch: %v", err), http.StatusInternalServerError)
+
+	if s.config.WebhookSecret == "" {
+		logger.Error("rejecting webhook request: no WebhookSecret configured")
+		dispatchRequestsTotal.WithLabelValues("rejected", "webhook_not_configured").Inc()
+		http.Error(w, "Webhook is not configured", http.StatusUnauthorized)
 		return
 	}
-	log.Printf("Successfully dispatched for application '%s' with commit hash '%s'", applicationName, commitHash)
-	
-	// Return success response
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(map[string]string{
-		"status":     "success",
-		"commitHash": commitHash,
-		"sourceName": applicationName,
-	})
+
+	if !verifyWebhookSignature(s.config.WebhookSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		logger.Warn("rejecting webhook request: signature mismatch")
+		dispatchRequestsTotal.WithLabelValues("rejected", "signature_mismatch").Inc()
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	eventType := r.Header.Get("X-GitHub-Event")
+	logger = logger.With("event_type", eventType)
+	applicationName, ok, err := applicationFromWebhookEvent(eventType, body)
+	if err != nil {
+		logger.Error("deriving application from event", "error", err)
+		dispatchRequestsTotal.WithLabelValues("rejected", "unsupported_event").Inc()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		logger.Info("skipping event - doesn't map to a PR preview")
+		dispatchRequestsTotal.WithLabelValues("skipped", "not_a_pr_event").Inc()
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "skipped",
+			"reason": "event doesn't map to a PR preview application",
+		})
+		return
+	}
+	logger = logger.With("application", applicationName)
+
+	githubToken, err := s.resolveGitHubToken(ctx, "")
+	if err != nil {
+		logger.Error("resolving GitHub token", "error", err)
+		dispatchRequestsTotal.WithLabelValues("rejected", "missing_token").Inc()
+		http.Error(w, "A GitHub token is required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := s.dispatcher.Enqueue(applicationName, githubToken, requestIDFromContext(ctx))
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
+		logger.Error("enqueueing job", "error", err)
+		dispatchRequestsTotal.WithLabelValues("rejected", "enqueue_failed").Inc()
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	logger.Info("enqueued job", "job_id", job.ID)
+	dispatchRequestsTotal.WithLabelValues("accepted", "").Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "accepted",
+		"jobId":      job.ID,
+		"sourceName": applicationName,
+	})
 }
 
 // healthHandler handles health check endpoint
@@ -266,31 +480,106 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
 }
 
+// handleJobs handles `/jobs?state=<state>`, listing jobs for operators.
+// An empty state lists jobs in every state.
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobs, err := s.dispatcher.store.List(JobState(r.URL.Query().Get("state")))
+	if err != nil {
+		log.Printf("Error listing jobs: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// handleJobByID handles `/jobs/{id}`, returning the status of a single job.
+func (s *Server) handleJobByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	if id == "" {
+		s.handleJobs(w, r)
+		return
+	}
+
+	job, err := s.dispatcher.store.Get(id)
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error fetching job '%s': %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
 func main() {
 	// Load configuration from environment variables
 	config := Config{
-		Port:              getEnv("PORT", "9555"),
-		GitHubAPIURL:      getEnv("GITHUB_API_URL", "https://api.github.com"),
-		TargetRepo:        getEnv("TARGET_REPO", "sematext/sematext-cloud"),
-		DeploymentRepo:     getEnv("DEPLOYMENT_REPO", "sematext/deployment"),
-		GitHubToken:       getEnv("GITHUB_TOKEN", ""), // Can also be passed as a request parameter if not set here
+		Port:                    getEnv("PORT", "9555"),
+		GitHubAPIURL:            getEnv("GITHUB_API_URL", "https://api.github.com"),
+		TargetRepo:              getEnv("TARGET_REPO", "sematext/sematext-cloud"),
+		DeploymentRepo:          getEnv("DEPLOYMENT_REPO", "sematext/deployment"),
+		GitHubToken:             getEnv("GITHUB_TOKEN", ""), // Can also be passed as a request parameter if not set here
+		WebhookSecret:           getEnv("WEBHOOK_SECRET", ""),
+		GitHubAppID:             getEnvInt64("GITHUB_APP_ID", 0),
+		GitHubAppPrivateKey:     getEnv("GITHUB_APP_PRIVATE_KEY", ""),
+		GitHubAppInstallationID: getEnvInt64("GITHUB_APP_INSTALLATION_ID", 0),
+		DeploymentSourceKind:    getEnv("DEPLOYMENT_SOURCE_KIND", "github"),
+		DeploymentSourceBaseURL: getEnv("DEPLOYMENT_SOURCE_BASE_URL", ""),
+		DeploymentBranch:        getEnv("DEPLOYMENT_BRANCH", "master"),
+		PkgdashConfigPath:       getEnv("PKGDASH_CONFIG_PATH", "pkgdash.yml"),
+		JobQueueBackend:         getEnv("JOB_QUEUE_BACKEND", "memory"),
+		JobQueueBoltPath:        getEnv("JOB_QUEUE_BOLT_PATH", "jobs.db"),
+		JobWorkers:              getEnvInt("JOB_WORKERS", 4),
+		JobMaxAttempts:          getEnvInt("JOB_MAX_ATTEMPTS", 8),
 	}
-	
+
 	// Create server
-	server := NewServer(config)
-	
+	server, err := NewServer(config)
+	if err != nil {
+		log.Fatal("Failed to configure server:", err)
+	}
+	server.dispatcher.Run(context.Background(), config.JobWorkers)
+
 	// Setup routes
-	http.HandleFunc("/dispatch", server.handleDispatch)
+	http.HandleFunc("/dispatch", requestIDMiddleware(server.handleDispatch))
+	http.HandleFunc("/webhook", requestIDMiddleware(server.handleWebhook))
+	http.HandleFunc("/jobs", server.handleJobs)
+	http.HandleFunc("/jobs/", server.handleJobByID)
 	http.HandleFunc("/health", server.healthHandler)
-	
+	http.Handle("/metrics", promhttp.Handler())
+
 	// Start server
 	addr := ":" + config.Port
 	log.Printf("Starting server on %s", addr)
 	log.Printf("Configuration:")
 	log.Printf("  Target Repository: %s", config.TargetRepo)
-	log.Printf("  Deployment Repository: %s", config.DeploymentRepo)
+	log.Printf("  Deployment Repository: %s (%s, branch %s)", config.DeploymentRepo, config.DeploymentSourceKind, server.pkgdashConfig.Branch)
+	if config.WebhookSecret != "" {
+		log.Printf("  Webhook: enabled on /webhook")
+	}
 	log.Printf("  GitHub API URL: %s", config.GitHubAPIURL)
-	
+	if config.GitHubAppID != 0 {
+		log.Printf("  GitHub App: authenticating as App %d, installation %d", config.GitHubAppID, config.GitHubAppInstallationID)
+	}
+	log.Printf("  Job Queue: %s backend, %d workers, max %d attempts", config.JobQueueBackend, config.JobWorkers, config.JobMaxAttempts)
+	log.Printf("  Metrics: enabled on /metrics")
+
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
@@ -302,4 +591,26 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
+}
+
+// getEnvInt64 gets an environment variable with a fallback default value,
+// parsing it as an int64. An unparseable value is treated as unset.
+func getEnvInt64(key string, defaultValue int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %v, using default", key, err)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvInt gets an environment variable with a fallback default value,
+// parsing it as an int. An unparseable value is treated as unset.
+func getEnvInt(key string, defaultValue int) int {
+	return int(getEnvInt64(key, int64(defaultValue)))
 }
\ No newline at end of file
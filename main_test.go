@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func signBody(t *testing.T, secret string, body []byte) string {
+	t.Helper()
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret := "s3cret"
+	body := []byte(`{"hello":"world"}`)
+	valid := signBody(t, secret, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid signature", secret, body, valid, true},
+		{"wrong secret", "other-secret", body, valid, false},
+		{"tampered body", secret, []byte(`{"hello":"mars"}`), valid, false},
+		{"missing prefix", secret, body, valid[len("sha256="):], false},
+		{"empty header", secret, body, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyWebhookSignature(tt.secret, tt.body, tt.signature); got != tt.want {
+				t.Errorf("verifyWebhookSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplicationFromWebhookEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		body      string
+		wantApp   string
+		wantOK    bool
+		wantErr   bool
+	}{
+		{
+			name:      "pull_request opened",
+			eventType: "pull_request",
+			body:      `{"action":"opened","number":42,"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantApp:   "pr-42",
+			wantOK:    true,
+		},
+		{
+			name:      "pull_request synchronize",
+			eventType: "pull_request",
+			body:      `{"action":"synchronize","number":42,"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantApp:   "pr-42",
+			wantOK:    true,
+		},
+		{
+			name:      "pull_request closed is not a deploy action",
+			eventType: "pull_request",
+			body:      `{"action":"closed","number":42,"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantOK:    false,
+		},
+		{
+			name:      "pull_request labeled is not a deploy action",
+			eventType: "pull_request",
+			body:      `{"action":"labeled","number":42,"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantOK:    false,
+		},
+		{
+			name:      "workflow_run completed successfully with pull requests",
+			eventType: "workflow_run",
+			body:      `{"workflow_run":{"status":"completed","conclusion":"success","pull_requests":[{"number":7}]},"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantApp:   "pr-7",
+			wantOK:    true,
+		},
+		{
+			name:      "workflow_run completed successfully without pull requests",
+			eventType: "workflow_run",
+			body:      `{"workflow_run":{"status":"completed","conclusion":"success","pull_requests":[]},"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantOK:    false,
+		},
+		{
+			name:      "workflow_run still in progress is skipped",
+			eventType: "workflow_run",
+			body:      `{"workflow_run":{"status":"in_progress","conclusion":"","pull_requests":[{"number":7}]},"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantOK:    false,
+		},
+		{
+			name:      "workflow_run completed with failure is skipped",
+			eventType: "workflow_run",
+			body:      `{"workflow_run":{"status":"completed","conclusion":"failure","pull_requests":[{"number":7}]},"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantOK:    false,
+		},
+		{
+			name:      "deployment_status success for a PR environment",
+			eventType: "deployment_status",
+			body:      `{"deployment_status":{"state":"success"},"deployment":{"environment":"pr-9"},"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantApp:   "pr-9",
+			wantOK:    true,
+		},
+		{
+			name:      "deployment_status success for a non-PR environment",
+			eventType: "deployment_status",
+			body:      `{"deployment_status":{"state":"success"},"deployment":{"environment":"production"},"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantOK:    false,
+		},
+		{
+			name:      "deployment_status pending is skipped",
+			eventType: "deployment_status",
+			body:      `{"deployment_status":{"state":"pending"},"deployment":{"environment":"pr-9"},"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantOK:    false,
+		},
+		{
+			name:      "deployment_status failure is skipped",
+			eventType: "deployment_status",
+			body:      `{"deployment_status":{"state":"failure"},"deployment":{"environment":"pr-9"},"repository":{"full_name":"sematext/gh-runner"}}`,
+			wantOK:    false,
+		},
+		{
+			name:      "ping is skipped, not an error",
+			eventType: "ping",
+			body:      `{"zen":"Keep it logically awesome."}`,
+			wantOK:    false,
+		},
+		{
+			name:      "unsupported event type",
+			eventType: "issue_comment",
+			body:      `{}`,
+			wantErr:   true,
+		},
+		{
+			name:      "malformed payload",
+			eventType: "pull_request",
+			body:      `not json`,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, ok, err := applicationFromWebhookEvent(tt.eventType, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applicationFromWebhookEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if ok != tt.wantOK {
+				t.Errorf("applicationFromWebhookEvent() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && app != tt.wantApp {
+				t.Errorf("applicationFromWebhookEvent() app = %q, want %q", app, tt.wantApp)
+			}
+		})
+	}
+}
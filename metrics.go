@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/oauth2"
+)
+
+var (
+	dispatchRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dispatch_requests_total",
+		Help: "Count of /dispatch and /webhook requests by result and reason.",
+	}, []string{"result", "reason"})
+
+	githubAPIRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_requests_total",
+		Help: "Count of outbound GitHub/GitLab/Gitea API requests by endpoint and status.",
+	}, []string{"endpoint", "status"})
+
+	githubRateLimitRemaining = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "github_rate_limit_remaining",
+		Help: "Most recently observed X-RateLimit-Remaining value from the GitHub API.",
+	})
+
+	fetchValuesDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "fetch_values_duration_seconds",
+		Help: "Time spent fetching and parsing a deployment's values file.",
+	})
+
+	dispatchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "dispatch_duration_seconds",
+		Help: "Time spent sending a repository_dispatch event to GitHub.",
+	})
+)
+
+// metricsTransport wraps an http.RoundTripper to record
+// github_api_requests_total and github_rate_limit_remaining for every
+// outbound request it carries.
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+// newMetricsTransport wraps next (or http.DefaultTransport if nil).
+func newMetricsTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &metricsTransport{next: next}
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		githubAPIRequestsTotal.WithLabelValues(classifyEndpoint(req.URL.Path), "error").Inc()
+		return resp, err
+	}
+
+	githubAPIRequestsTotal.WithLabelValues(classifyEndpoint(req.URL.Path), strconv.Itoa(resp.StatusCode)).Inc()
+
+	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+		if value, err := strconv.ParseFloat(remaining, 64); err == nil {
+			githubRateLimitRemaining.Set(value)
+		}
+	}
+
+	return resp, nil
+}
+
+// classifyEndpoint maps a request path to a low-cardinality label; the raw
+// path embeds application names and file paths, which would blow up metric
+// cardinality if used directly.
+func classifyEndpoint(path string) string {
+	switch {
+	case strings.Contains(path, "/dispatches"):
+		return "dispatches"
+	case strings.Contains(path, "/contents/"):
+		return "contents"
+	case strings.Contains(path, "/access_tokens"):
+		return "access_tokens"
+	case strings.Contains(path, "/repository/files/"):
+		return "gitlab_files"
+	case strings.Contains(path, "/raw/"):
+		return "gitea_raw"
+	default:
+		return "other"
+	}
+}
+
+// instrumentedHTTPClient returns a copy of base whose Transport records
+// outbound API metrics.
+func instrumentedHTTPClient(base *http.Client) *http.Client {
+	return &http.Client{
+		Transport: newMetricsTransport(base.Transport),
+		Timeout:   base.Timeout,
+	}
+}
+
+// withInstrumentedHTTPClient attaches an instrumented HTTP client to ctx so
+// oauth2.NewClient uses it as the base transport for token-authenticated
+// requests, keeping metrics uniform across plain and oauth2-wrapped clients.
+func withInstrumentedHTTPClient(ctx context.Context, base *http.Client) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, instrumentedHTTPClient(base))
+}
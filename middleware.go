@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+type contextKey string
+
+const requestIDContextKey contextKey = "requestID"
+
+// requestIDMiddleware assigns each request a request ID (reusing the
+// caller's X-Request-ID if present), echoes it back on the response, and
+// attaches it to the request context so handlers and the jobs they enqueue
+// can log it.
+func requestIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+		next(w, r.WithContext(contextWithRequestID(r.Context(), requestID)))
+	}
+}
+
+// contextWithRequestID attaches requestID to ctx.
+func contextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// requestIDFromContext returns the request ID attached by
+// requestIDMiddleware (or contextWithRequestID), or "" if none is set.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// newRequestID generates an ID for requests that didn't supply their own.
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// requestLogger returns a logger carrying the request ID from ctx, for use
+// throughout the dispatch pipeline.
+func requestLogger(ctx context.Context) *slog.Logger {
+	return slog.Default().With("request_id", requestIDFromContext(ctx))
+}
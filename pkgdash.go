@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PkgdashConfig describes how to locate and parse a deployment's values
+// file: which path templates to try for a given application, and which
+// dotted key holds the deployment tag. It is loaded from a `pkgdash.yml`
+// file so teams with different repo layouts don't have to fork the binary.
+type PkgdashConfig struct {
+	Branch        string   `yaml:"branch"`
+	PathTemplates []string `yaml:"pathTemplates"`
+	TagSelector   string   `yaml:"tagSelector"`
+}
+
+// DefaultPkgdashConfig matches the service's original hardcoded behavior.
+func DefaultPkgdashConfig() PkgdashConfig {
+	return PkgdashConfig{
+		Branch: "master",
+		PathTemplates: []string{
+			"configs/pr/light/{{.Application}}/values.yaml",
+			"configs/pr/heavy/{{.Application}}/values.yaml",
+		},
+		TagSelector: "global.config.DEPLOYMENT_TAG",
+	}
+}
+
+// LoadPkgdashConfig reads a pkgdash.yml file at path, overlaying it on
+// DefaultPkgdashConfig. A missing file is not an error: the service keeps
+// working with the defaults.
+func LoadPkgdashConfig(path string) (PkgdashConfig, error) {
+	config := DefaultPkgdashConfig()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return PkgdashConfig{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return PkgdashConfig{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// RenderPaths renders each configured path template for the given
+// application name, in order. application is attacker-controlled (it comes
+// straight off `/dispatch` or a webhook payload), so every rendered path is
+// sanitized to ensure it can't escape the directory the template put it in.
+func (c PkgdashConfig) RenderPaths(application string) ([]string, error) {
+	data := struct{ Application string }{Application: application}
+
+	paths := make([]string, 0, len(c.PathTemplates))
+	for _, tmplText := range c.PathTemplates {
+		tmpl, err := template.New("path").Parse(tmplText)
+		if err != nil {
+			return nil, fmt.Errorf("parsing path template %q: %w", tmplText, err)
+		}
+
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("rendering path template %q: %w", tmplText, err)
+		}
+
+		rendered, err := sanitizeRenderedPath(buf.String())
+		if err != nil {
+			return nil, fmt.Errorf("path template %q: %w", tmplText, err)
+		}
+		paths = append(paths, rendered)
+	}
+
+	return paths, nil
+}
+
+// sanitizeRenderedPath rejects a rendered path template that contains a
+// ".." segment or is absolute (e.g. an application name like
+// "pr-../../../../etc/passwd" substituted into the template), rather than
+// letting it resolve to a path outside the directory the template put it
+// in, or to an arbitrary repo path the template didn't intend.
+func sanitizeRenderedPath(renderedPath string) (string, error) {
+	if path.IsAbs(renderedPath) {
+		return "", fmt.Errorf("rendered path %q must be relative", renderedPath)
+	}
+	for _, segment := range strings.Split(renderedPath, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("rendered path %q contains a '..' segment", renderedPath)
+		}
+	}
+	return path.Clean(renderedPath), nil
+}
+
+// ExtractTag walks content (parsed as YAML) along the dotted TagSelector
+// (e.g. "global.config.DEPLOYMENT_TAG") and returns the scalar value found
+// there.
+func (c PkgdashConfig) ExtractTag(content []byte) (string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return "", fmt.Errorf("parsing YAML: %w", err)
+	}
+	if len(root.Content) == 0 {
+		return "", fmt.Errorf("empty YAML document")
+	}
+
+	node := root.Content[0]
+	for _, key := range strings.Split(c.TagSelector, ".") {
+		node = lookupMapKey(node, key)
+		if node == nil {
+			return "", fmt.Errorf("tag selector %q: key %q not found", c.TagSelector, key)
+		}
+	}
+
+	if node.Kind != yaml.ScalarNode || node.Value == "" {
+		return "", fmt.Errorf("tag selector %q did not resolve to a non-empty value", c.TagSelector)
+	}
+
+	return node.Value, nil
+}
+
+// lookupMapKey returns the value node for key within a YAML mapping node,
+// or nil if node isn't a mapping or doesn't contain key.
+func lookupMapKey(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import "testing"
+
+func TestRenderPaths(t *testing.T) {
+	config := PkgdashConfig{
+		PathTemplates: []string{
+			"configs/pr/light/{{.Application}}/values.yaml",
+			"configs/pr/heavy/{{.Application}}/values.yaml",
+		},
+	}
+
+	tests := []struct {
+		name        string
+		application string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "normal application name",
+			application: "pr-123",
+			want: []string{
+				"configs/pr/light/pr-123/values.yaml",
+				"configs/pr/heavy/pr-123/values.yaml",
+			},
+		},
+		{
+			name:        "path traversal is rejected",
+			application: "pr-../../../../etc/passwd",
+			wantErr:     true,
+		},
+		{
+			name:        "deeply nested traversal is rejected",
+			application: "pr-" + "../../../../../../../../etc/passwd",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.RenderPaths(tt.application)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("RenderPaths() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("RenderPaths()[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExtractTag(t *testing.T) {
+	config := PkgdashConfig{TagSelector: "global.config.DEPLOYMENT_TAG"}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "tag present",
+			content: `
+global:
+  config:
+    DEPLOYMENT_TAG: abc123
+`,
+			want: "abc123",
+		},
+		{
+			name: "missing key",
+			content: `
+global:
+  config: {}
+`,
+			wantErr: true,
+		},
+		{
+			name: "empty value",
+			content: `
+global:
+  config:
+    DEPLOYMENT_TAG: ""
+`,
+			wantErr: true,
+		},
+		{
+			name:    "empty document",
+			content: ``,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.ExtractTag([]byte(tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExtractTag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ExtractTag() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/v57/github"
+	"golang.org/x/oauth2"
+)
+
+// SourceProvider fetches a file's raw content from wherever a team's
+// deployment config lives, so the dispatch pipeline isn't tied to GitHub.
+type SourceProvider interface {
+	FetchFile(ctx context.Context, path string) ([]byte, error)
+}
+
+// NewSourceProvider builds a SourceProvider for kind ("github", "gitlab",
+// "gitea", or "local"), selected via DEPLOYMENT_SOURCE_KIND. repo is the
+// "owner/repo" slug for git-hosted kinds, or a base directory for "local".
+func NewSourceProvider(kind, repo, branch, baseURL string, authProvider AuthProvider, httpClient *http.Client) (SourceProvider, error) {
+	switch kind {
+	case "", "github":
+		return &githubSourceProvider{repo: repo, branch: branch, baseURL: baseURL, authProvider: authProvider, httpClient: httpClient}, nil
+	case "gitlab":
+		return &gitlabSourceProvider{repo: repo, branch: branch, baseURL: baseURL, authProvider: authProvider, httpClient: instrumentedHTTPClient(httpClient)}, nil
+	case "gitea":
+		return &giteaSourceProvider{repo: repo, branch: branch, baseURL: baseURL, authProvider: authProvider, httpClient: instrumentedHTTPClient(httpClient)}, nil
+	case "local":
+		return &localSourceProvider{baseDir: repo}, nil
+	default:
+		return nil, fmt.Errorf("unknown deployment source kind %q", kind)
+	}
+}
+
+// githubSourceProvider fetches files via the GitHub Contents API.
+type githubSourceProvider struct {
+	repo         string // "owner/repo"
+	branch       string
+	baseURL      string
+	authProvider AuthProvider
+	httpClient   *http.Client
+}
+
+func (p *githubSourceProvider) FetchFile(ctx context.Context, path string) ([]byte, error) {
+	parts := strings.Split(p.repo, "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid repository format %q", p.repo)
+	}
+
+	token, err := p.authProvider.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolving github token: %w", err)
+	}
+
+	ctx = withInstrumentedHTTPClient(ctx, p.httpClient)
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	client := github.NewClient(oauth2.NewClient(ctx, ts))
+	if p.baseURL != "" && p.baseURL != "https://api.github.com" {
+		client, err = client.WithEnterpriseURLs(p.baseURL, p.baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("configuring enterprise URL: %w", err)
+		}
+	}
+
+	fileContent, _, resp, err := client.Repositories.GetContents(ctx, parts[0], parts[1], path, &github.RepositoryContentGetOptions{Ref: p.branch})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("file not found at %s", path)
+		}
+		return nil, fmt.Errorf("fetching file: %w", err)
+	}
+
+	content, err := fileContent.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("decoding file content: %w", err)
+	}
+
+	return []byte(content), nil
+}
+
+// gitlabSourceProvider fetches files via GitLab's raw file API:
+// GET /api/v4/projects/:id/repository/files/:file_path/raw?ref=branch
+type gitlabSourceProvider struct {
+	repo         string // "group/project"
+	branch       string
+	baseURL      string // defaults to https://gitlab.com
+	authProvider AuthProvider
+	httpClient   *http.Client
+}
+
+func (p *gitlabSourceProvider) FetchFile(ctx context.Context, path string) ([]byte, error) {
+	baseURL := p.baseURL
+	if baseURL == "" {
+		baseURL = "https://gitlab.com"
+	}
+
+	reqURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/files/%s/raw?ref=%s",
+		baseURL, url.PathEscape(p.repo), url.PathEscape(path), url.QueryEscape(p.branch))
+
+	token, err := p.authProvider.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolving gitlab token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("file not found at %s", path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// giteaSourceProvider fetches files via Gitea's raw file API:
+// GET /api/v1/repos/:owner/:repo/raw/:path?ref=branch
+type giteaSourceProvider struct {
+	repo         string // "owner/repo"
+	branch       string
+	baseURL      string // e.g. https://gitea.example.com
+	authProvider AuthProvider
+	httpClient   *http.Client
+}
+
+func (p *giteaSourceProvider) FetchFile(ctx context.Context, path string) ([]byte, error) {
+	if p.baseURL == "" {
+		return nil, fmt.Errorf("gitea source requires a base URL")
+	}
+
+	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/raw/%s?ref=%s", p.baseURL, p.repo, path, url.QueryEscape(p.branch))
+
+	token, err := p.authProvider.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("resolving gitea token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "token "+token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("file not found at %s", path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// localSourceProvider reads files from the local filesystem, rooted at
+// baseDir. It exists so tests don't need network access.
+type localSourceProvider struct {
+	baseDir string
+}
+
+func (p *localSourceProvider) FetchFile(ctx context.Context, path string) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(p.baseDir, path))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("file not found at %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	return content, nil
+}